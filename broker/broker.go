@@ -1,44 +1,602 @@
 package main
 
 import (
+	"container/list"
+	"flag"
 	"log"
+	"log/slog"
+	"time"
 
+	appconfig "github.com/lucasdias-03/sistemas-distribuidos-projeto/config"
+	applog "github.com/lucasdias-03/sistemas-distribuidos-projeto/logging"
+	appmetrics "github.com/lucasdias-03/sistemas-distribuidos-projeto/metrics"
+	"github.com/lucasdias-03/sistemas-distribuidos-projeto/zmqutil"
 	zmq "github.com/pebbe/zmq4"
 )
 
+// captureMirrorAddr e onde o capture tap e espelhado para fora do processo,
+// para que cmd/monitor possa observar o trafego sem acoplamento ao broker.
+const captureMirrorAddr = "tcp://*:5559"
+
+// Protocolo Majordomo (MDP) v0.2 - cabecalhos usados nos frames de cliente e worker.
+const (
+	mdpClient = "MDPC01"
+	mdpWorker = "MDPW01"
+
+	mdpReady      = "\x01"
+	mdpRequest    = "\x02"
+	mdpReply      = "\x03"
+	mdpHeartbeat  = "\x04"
+	mdpDisconnect = "\x05"
+)
+
+const (
+	heartbeatInterval = 2500 * time.Millisecond // intervalo entre heartbeats broker<->worker
+	heartbeatLiveness = 3                       // heartbeats perdidos ate considerar o worker morto
+	maxRetries        = 3                       // tentativas de reenvio antes de responder "service unavailable"
+)
+
+// worker representa um worker MDP registrado junto ao broker.
+type worker struct {
+	identity string          // identidade de roteamento do ROUTER (envelope)
+	service  *service        // servico ao qual o worker esta associado
+	expiry   time.Time       // instante em que o worker e considerado morto se nao houver heartbeat
+	inFlight *pendingRequest // requisicao atualmente roteada a este worker, se houver
+}
+
+// pendingRequest e uma requisicao de cliente aguardando (ou aguardando de novo) um worker livre.
+type pendingRequest struct {
+	service    *service
+	clientID   string
+	frames     [][]byte
+	retries    int
+	receivedAt time.Time // para zmq_forward_latency_seconds quando a resposta sair
+}
+
+// service agrupa os workers prontos e as requisicoes pendentes de um mesmo nome de servico.
+type service struct {
+	name     string
+	waiting  *list.List // workers prontos (FIFO) - elemento: *worker
+	requests *list.List // requisicoes de clientes aguardando worker - elemento: *pendingRequest
+}
+
+// msgSocket e o subconjunto de *zmq.Socket usado pelo broker para enviar
+// frames - extraido para permitir exercitar o roteamento MDP em teste sem
+// precisar de sockets ZMQ reais.
+type msgSocket interface {
+	SendMessage(parts ...interface{}) (int, error)
+}
+
+// broker mantem o estado de roteamento do Majordomo: servicos, workers e o socket do frontend.
+type broker struct {
+	frontend msgSocket
+	backend  msgSocket
+	capture  msgSocket // opcional - espelha todo frame roteado para cmd/monitor
+	logger   *slog.Logger
+	services map[string]*service
+	workers  map[string]*worker // identity -> worker
+}
+
+// newBroker recebe capture como *zmq.Socket (em vez de msgSocket) para evitar
+// a armadilha classica de Go: se um *zmq.Socket nil fosse guardado direto numa
+// interface, b.capture == nil em mirror() passaria a dar false.
+func newBroker(frontend, backend msgSocket, capture *zmq.Socket, logger *slog.Logger) *broker {
+	b := &broker{
+		frontend: frontend,
+		backend:  backend,
+		logger:   logger,
+		services: make(map[string]*service),
+		workers:  make(map[string]*worker),
+	}
+	if capture != nil {
+		b.capture = capture
+	}
+	return b
+}
+
+// mirror envia uma copia dos frames ao capture tap, se houver um configurado.
+func (b *broker) mirror(frames [][]byte) {
+	if b.capture == nil {
+		return
+	}
+	if _, err := b.capture.SendMessage(frames); err != nil {
+		b.logger.Error("erro ao espelhar frames no capture tap", "err", err)
+	}
+}
+
+// forwarded registra no Prometheus um frame que acabou de ser enviado por um
+// socket, com seu tamanho total em bytes.
+func forwarded(direction, socket string, frames [][]byte) {
+	size := 0
+	for _, f := range frames {
+		size += len(f)
+	}
+	appmetrics.FramesForwarded.WithLabelValues(direction, socket).Inc()
+	appmetrics.BytesForwarded.WithLabelValues(direction, socket).Add(float64(size))
+}
+
+func (b *broker) serviceFor(name string) *service {
+	s, ok := b.services[name]
+	if !ok {
+		s = &service{
+			name:     name,
+			waiting:  list.New(),
+			requests: list.New(),
+		}
+		b.services[name] = s
+	}
+	return s
+}
+
+// dispatch casa requisicoes pendentes com workers prontos enquanto houver ambos disponiveis.
+func (b *broker) dispatch(s *service) {
+	for s.requests.Len() > 0 && s.waiting.Len() > 0 {
+		req := s.requests.Remove(s.requests.Front()).(*pendingRequest)
+		w := s.waiting.Remove(s.waiting.Front()).(*worker)
+		w.inFlight = req
+
+		frames := append([][]byte{[]byte(w.identity), nil, []byte(mdpWorker), []byte(mdpRequest),
+			[]byte(req.clientID), nil}, req.frames...)
+		b.sendToBackend(frames)
+	}
+}
+
+// requeue devolve uma requisicao ao fim da fila do servico, ate o limite de tentativas;
+// depois disso, responde ao cliente que o servico esta indisponivel.
+func (b *broker) requeue(s *service, req *pendingRequest) {
+	req.retries++
+	if req.retries > maxRetries {
+		b.logger.Warn("servico indisponivel, avisando cliente", "service", s.name, "retries", maxRetries)
+		b.sendToFrontend([][]byte{[]byte(req.clientID), nil, []byte(mdpClient), []byte(s.name),
+			[]byte("ERROR"), []byte("service unavailable")})
+		return
+	}
+	s.requests.PushBack(req)
+}
+
+func (b *broker) sendToFrontend(frames [][]byte) {
+	if _, err := b.frontend.SendMessage(frames); err != nil {
+		// Com ROUTER_MANDATORY, EHOSTUNREACH so acontece quando o cliente de
+		// destino ja se desconectou - nao ha worker para reenfileirar, o
+		// cliente que pediu nao esta mais la para reler a resposta.
+		//
+		// zmq4 nao propaga erros de socket como syscall.Errno: SendMessage
+		// roda o errno da chamada cgo por zmq4.errget(), que devolve o tipo
+		// proprio do pacote (zmq4.Errno, um uintptr) - comparar com
+		// syscall.EHOSTUNREACH nunca da match.
+		if err == zmq.EHOSTUNREACH {
+			b.logger.Warn("cliente desconectado antes da resposta chegar, descartando")
+		} else {
+			b.logger.Error("erro ao enviar frames pelo frontend", "socket", "frontend", "err", err)
+		}
+	}
+	forwarded("worker_to_client", "frontend", frames)
+	b.mirror(frames)
+}
+
+func (b *broker) sendToBackend(frames [][]byte) {
+	if _, err := b.backend.SendMessage(frames); err != nil {
+		b.logger.Error("erro ao enviar frames pelo backend", "socket", "backend", "err", err)
+	}
+	forwarded("client_to_worker", "backend", frames)
+	b.mirror(frames)
+}
+
+// workerWaiting marca o worker como pronto para receber trabalho do seu servico.
+func (b *broker) workerWaiting(w *worker) {
+	w.expiry = time.Now().Add(heartbeatInterval * heartbeatLiveness)
+	w.service.waiting.PushBack(w)
+	b.dispatch(w.service)
+}
+
+// purgeExpiredWorkers remove workers que nao respondem ha heartbeatLiveness intervalos
+// e re-enfileira qualquer requisicao que estivesse em voo para eles.
+func (b *broker) purgeExpiredWorkers() {
+	now := time.Now()
+	for id, w := range b.workers {
+		if now.After(w.expiry) {
+			b.logger.Info("worker expirou, sem heartbeat", "worker", id, "service", w.service.name)
+			b.removeWorker(w)
+		}
+	}
+}
+
+// removeWorker tira o worker do servico e, se ele tinha uma requisicao em voo,
+// devolve essa requisicao para a fila (ou falha o cliente apos maxRetries).
+func (b *broker) removeWorker(w *worker) {
+	delete(b.workers, w.identity)
+	for e := w.service.waiting.Front(); e != nil; e = e.Next() {
+		if e.Value.(*worker) == w {
+			w.service.waiting.Remove(e)
+			break
+		}
+	}
+	if w.inFlight != nil {
+		b.requeue(w.service, w.inFlight)
+		w.inFlight = nil
+		b.dispatch(w.service)
+	}
+	appmetrics.ActivePeers.WithLabelValues("backend").Set(float64(len(b.workers)))
+}
+
+// handleWorkerFrame processa um comando MDPW01 vindo do backend.
+func (b *broker) handleWorkerFrame(identity string, command string, rest []string) {
+	switch command {
+	case mdpReady:
+		if len(rest) < 1 {
+			b.logger.Warn("READY sem nome de servico, ignorando")
+			return
+		}
+		// MDP v0.2: um worker que reenvia READY com uma identidade ja conhecida
+		// esta se reconectando (ex.: apos um crash/restart) - o registro antigo
+		// precisa ser removido do seu servico anterior antes de criar o novo,
+		// senao ele fica preso na waiting list errada e pode receber um REQUEST
+		// de um servico que esse worker ja nao atende.
+		if old, ok := b.workers[identity]; ok {
+			b.removeWorker(old)
+		}
+		svc := b.serviceFor(rest[0])
+		w := &worker{identity: identity, service: svc}
+		b.workers[identity] = w
+		b.workerWaiting(w)
+		b.logger.Info("worker registrado", "worker", identity, "service", svc.name)
+		appmetrics.ActivePeers.WithLabelValues("backend").Set(float64(len(b.workers)))
+
+	case mdpReply:
+		w, ok := b.workers[identity]
+		if !ok || len(rest) < 2 {
+			b.logger.Warn("REPLY de worker desconhecido, ignorando", "worker", identity)
+			return
+		}
+		clientID, reply := rest[0], rest[1:]
+		frames := [][]byte{[]byte(clientID), nil, []byte(mdpClient), []byte(w.service.name)}
+		for _, r := range reply {
+			frames = append(frames, []byte(r))
+		}
+		if w.inFlight != nil {
+			appmetrics.ForwardLatency.WithLabelValues(w.service.name).Observe(time.Since(w.inFlight.receivedAt).Seconds())
+		}
+		b.sendToFrontend(frames)
+		w.inFlight = nil
+		b.workerWaiting(w)
+
+	case mdpHeartbeat:
+		if w, ok := b.workers[identity]; ok {
+			w.expiry = time.Now().Add(heartbeatInterval * heartbeatLiveness)
+		}
+
+	case mdpDisconnect:
+		if w, ok := b.workers[identity]; ok {
+			b.removeWorker(w)
+			b.logger.Info("worker desconectou-se explicitamente", "worker", identity)
+		}
+
+	default:
+		b.logger.Warn("comando MDPW01 desconhecido", "command", command)
+	}
+}
+
+// handleClientFrame processa uma requisicao MDPC01 vinda do frontend.
+func (b *broker) handleClientFrame(clientID string, serviceName string, request []string) {
+	s := b.serviceFor(serviceName)
+	frames := make([][]byte, len(request))
+	for i, r := range request {
+		frames[i] = []byte(r)
+	}
+	s.requests.PushBack(&pendingRequest{service: s, clientID: clientID, frames: frames, receivedAt: time.Now()})
+	appmetrics.ServiceRequests.WithLabelValues(serviceName).Inc()
+	b.dispatch(s)
+}
+
+// sendHeartbeats envia um heartbeat broker->worker para todo worker atualmente registrado.
+func (b *broker) sendHeartbeats() {
+	for _, w := range b.workers {
+		b.sendToBackend([][]byte{[]byte(w.identity), nil, []byte(mdpWorker), []byte(mdpHeartbeat)})
+	}
+}
+
+// defaultFrontendAddrs e defaultBackendAddrs sao usados quando --config nao e
+// informado ou nao descreve aquele socket, preservando o comportamento de hoje.
+var (
+	defaultFrontendAddrs = []string{"tcp://*:5555"}
+	defaultBackendAddrs  = []string{"tcp://*:5556"}
+)
+
+// newRouterSocket cria um ROUTER com tipo/curve/tuning/identity/ROUTER_MANDATORY
+// ja aplicados a partir de sc, e faz bind/connect em todos os enderecos
+// informados. curveKeysDir e o --curve-keys global; sc.CurveKeys, quando
+// presente, tem prioridade para esse socket especifico.
+func newRouterSocket(sc appconfig.SocketConfig, expectedType string, tuning zmqutil.SocketTuning,
+	curveKeysDir string, mandatory bool, logger *slog.Logger) (*zmq.Socket, error) {
+	if sc.Type != "" && sc.Type != expectedType {
+		logger.Warn("config.json declara um tipo de socket diferente do esperado",
+			"declared_type", sc.Type, "expected_type", expectedType)
+	}
+
+	sock, err := zmq.NewSocket(zmq.ROUTER)
+	if err != nil {
+		return nil, err
+	}
+
+	keysDir := curveKeysDir
+	if sc.CurveKeys != "" {
+		keysDir = sc.CurveKeys
+	}
+	if keysDir != "" {
+		if err := zmqutil.EnableCurveServer(sock, keysDir); err != nil {
+			sock.Close()
+			return nil, err
+		}
+	}
+
+	t := tuning
+	if sc.HWM > 0 {
+		t.Sndhwm, t.Rcvhwm = sc.HWM, sc.HWM
+	}
+	if err := t.Apply(sock); err != nil {
+		sock.Close()
+		return nil, err
+	}
+
+	if mandatory {
+		if err := sock.SetRouterMandatory(1); err != nil {
+			sock.Close()
+			return nil, err
+		}
+	}
+
+	if sc.Identity != "" {
+		if err := sock.SetIdentity(sc.Identity); err != nil {
+			sock.Close()
+			return nil, err
+		}
+	}
+
+	for _, addr := range sc.Bind {
+		if err := sock.Bind(addr); err != nil {
+			sock.Close()
+			return nil, err
+		}
+	}
+	for _, addr := range sc.Connect {
+		if err := sock.Connect(addr); err != nil {
+			sock.Close()
+			return nil, err
+		}
+	}
+	return sock, nil
+}
+
+// drainFrontend esvazia as requisicoes de cliente ja recebidas (mas ainda nao
+// lidas) do frontend antigo antes do Close durante um hot reload, processando
+// cada uma como o loop principal faria. ZMQ_LINGER so cobre envio pendente no
+// Close - mensagens que ja chegaram mas ainda nao foram lidas via RecvMessage
+// sao descartadas na hora, entao precisam ser puxadas e tratadas aqui antes.
+func drainFrontend(sock *zmq.Socket, b *broker) {
+	for {
+		msg, err := sock.RecvMessage(zmq.DONTWAIT)
+		if err != nil {
+			return
+		}
+		if len(msg) < 4 || msg[2] != mdpClient {
+			b.logger.Warn("frame de cliente malformado durante drain do rebind, ignorando")
+			continue
+		}
+		b.handleClientFrame(msg[0], msg[3], msg[4:])
+	}
+}
+
+// drainBackend e o analogo de drainFrontend para o backend antigo.
+func drainBackend(sock *zmq.Socket, b *broker) {
+	for {
+		msg, err := sock.RecvMessage(zmq.DONTWAIT)
+		if err != nil {
+			return
+		}
+		if len(msg) < 4 || msg[2] != mdpWorker {
+			b.logger.Warn("frame de worker malformado durante drain do rebind, ignorando")
+			continue
+		}
+		b.handleWorkerFrame(msg[0], msg[3], msg[4:])
+	}
+}
+
 func main() {
-	log.Println("Iniciando Broker...")
+	curveKeysDir := flag.String("curve-keys", "", "diretorio com server.key_secret e authorized_keys.txt "+
+		"(gerados por cmd/keygen) para habilitar CurveZMQ; vazio mantem o texto plano de hoje")
+	configPath := flag.String("config", "", "arquivo JSON com os bindings de socket (ver config.json); "+
+		"vazio usa as portas padrao 5555/5556 e desabilita o hot reload")
+	metricsAddr := flag.String("metrics-addr", ":9090", "endereco do servidor HTTP que expoe /metrics (Prometheus)")
+
+	tuning := zmqutil.DefaultTuning()
+	flag.IntVar(&tuning.Sndhwm, "sndhwm", tuning.Sndhwm, "ZMQ_SNDHWM do frontend e do backend (env SNDHWM)")
+	flag.IntVar(&tuning.Rcvhwm, "rcvhwm", tuning.Rcvhwm, "ZMQ_RCVHWM do frontend e do backend (env RCVHWM)")
+	flag.IntVar(&tuning.Sndbuf, "sndbuf", tuning.Sndbuf, "ZMQ_SNDBUF, 0 usa o default do SO (env SNDBUF)")
+	flag.IntVar(&tuning.Rcvbuf, "rcvbuf", tuning.Rcvbuf, "ZMQ_RCVBUF, 0 usa o default do SO (env RCVBUF)")
+	flag.DurationVar(&tuning.Linger, "linger", tuning.Linger, "ZMQ_LINGER ao fechar os sockets (env LINGER_MS)")
+	flag.BoolVar(&tuning.TcpKeepAlive, "tcp-keepalive", tuning.TcpKeepAlive, "ZMQ_TCP_KEEPALIVE (env TCP_KEEPALIVE)")
+	flag.Parse()
+
+	logger := applog.New()
+	appmetrics.StartServer(*metricsAddr)
+	logger.Info("iniciando broker (Majordomo Protocol v0.2)", "metrics_addr", *metricsAddr)
+
+	if *curveKeysDir != "" {
+		if err := zmqutil.StartCurveAuth(*curveKeysDir); err != nil {
+			log.Fatal("Erro ao iniciar autenticacao CurveZMQ:", err)
+		}
+		defer zmq.AuthStop()
+	}
+
+	var cfg *appconfig.Config
+	if *configPath != "" {
+		loaded, err := appconfig.Load(*configPath)
+		if err != nil {
+			log.Fatal("Erro ao carregar config:", err)
+		}
+		cfg = loaded
+	}
+
+	frontendSC := appconfig.ResolveSocket(cfg, "frontend", defaultFrontendAddrs)
+	backendSC := appconfig.ResolveSocket(cfg, "backend", defaultBackendAddrs)
 
 	// Socket ROUTER para clientes (frontend)
-	frontend, err := zmq.NewSocket(zmq.ROUTER)
+	frontend, err := newRouterSocket(frontendSC, "ROUTER", tuning, *curveKeysDir, true, logger)
 	if err != nil {
-		log.Fatal("Erro ao criar socket frontend:", err)
+		log.Fatal("Erro ao preparar o frontend:", err)
 	}
 	defer frontend.Close()
+	logger.Info("frontend (ROUTER) escutando", "bind", frontendSC.Bind, "connect", frontendSC.Connect)
 
-	err = frontend.Bind("tcp://*:5555")
+	// Socket ROUTER para workers (backend) - precisa ser ROUTER, nao DEALER,
+	// para que o broker consiga enderecar um worker especifico por servico.
+	backend, err := newRouterSocket(backendSC, "ROUTER", tuning, *curveKeysDir, false, logger)
 	if err != nil {
-		log.Fatal("Erro ao fazer bind no frontend:", err)
+		log.Fatal("Erro ao preparar o backend:", err)
 	}
-	log.Println("Frontend (ROUTER) escutando na porta 5555")
+	defer backend.Close()
+	logger.Info("backend (ROUTER) escutando", "bind", backendSC.Bind, "connect", backendSC.Connect)
 
-	// Socket DEALER para servidores (backend)
-	backend, err := zmq.NewSocket(zmq.DEALER)
-	if err != nil {
-		log.Fatal("Erro ao criar socket backend:", err)
+	// Capture tap opcional - espelha cada frame roteado para cmd/monitor.
+	capture := zmqutil.StartCaptureTap(captureMirrorAddr)
+	if capture != nil {
+		defer capture.Close()
 	}
-	defer backend.Close()
 
-	err = backend.Bind("tcp://*:5556")
-	if err != nil {
-		log.Fatal("Erro ao fazer bind no backend:", err)
+	b := newBroker(frontend, backend, capture, logger)
+
+	poller := zmq.NewPoller()
+	poller.Add(frontend, zmq.POLLIN)
+	poller.Add(backend, zmq.POLLIN)
+
+	// Hot reload: o watcher roda na sua propria goroutine e so manda a config
+	// recarregada por canal - quem de fato troca os sockets ZMQ (nao
+	// thread-safe) e sempre a goroutine principal, entre dois Polls.
+	var reloadCh chan *appconfig.Config
+	if *configPath != "" {
+		reloadCh = make(chan *appconfig.Config, 1)
+		watcher, err := appconfig.Watch(*configPath, func(newCfg *appconfig.Config) {
+			select {
+			case reloadCh <- newCfg:
+			default:
+			}
+		})
+		if err != nil {
+			logger.Warn("hot reload desabilitado, erro ao observar config", "err", err)
+		} else {
+			defer watcher.Close()
+			logger.Info("observando config para hot reload dos bindings", "path", *configPath)
+		}
 	}
-	log.Println("Backend (DEALER) escutando na porta 5556")
 
-	// Iniciar proxy (queue device) - faz round-robin automaticamente
-	log.Println("Broker rodando - fazendo proxy entre clientes e servidores...")
-	err = zmq.Proxy(frontend, backend, nil)
-	if err != nil {
-		log.Fatal("Erro no proxy:", err)
+	lastHeartbeat := time.Now()
+
+	logger.Info("broker rodando - roteando por servico com heartbeating e retries")
+	for {
+		if reloadCh != nil {
+			select {
+			case newCfg := <-reloadCh:
+				newFrontendSC := appconfig.ResolveSocket(newCfg, "frontend", defaultFrontendAddrs)
+				newBackendSC := appconfig.ResolveSocket(newCfg, "backend", defaultBackendAddrs)
+
+				// Fecha (apos drenar) o socket antigo antes de criar o novo: se so
+				// HWM/identity/curve_keys/subscribe mudaram, bind/connect continuam
+				// os mesmos, e o novo ROUTER nao consegue fazer bind no mesmo
+				// endereco enquanto o antigo ainda estiver escutando nele.
+				if !appconfig.SameSocket(newFrontendSC, frontendSC) {
+					oldFrontend := frontend
+					drainFrontend(oldFrontend, b)
+					if err := oldFrontend.Close(); err != nil {
+						logger.Warn("erro ao fechar frontend antigo", "err", err)
+					}
+
+					newFrontend, err := newRouterSocket(newFrontendSC, "ROUTER", tuning, *curveKeysDir, true, logger)
+					if err != nil {
+						logger.Error("erro ao rebindar frontend, tentando restaurar a config anterior", "err", err)
+						if restored, rerr := newRouterSocket(frontendSC, "ROUTER", tuning, *curveKeysDir, true, logger); rerr != nil {
+							log.Fatal("Erro ao restaurar o frontend apos falha no rebind:", rerr)
+						} else {
+							newFrontend = restored
+						}
+					} else {
+						frontendSC = newFrontendSC
+						logger.Info("frontend rebindado", "bind", frontendSC.Bind, "connect", frontendSC.Connect)
+					}
+					frontend = newFrontend
+					b.frontend = frontend
+					poller = zmq.NewPoller()
+					poller.Add(frontend, zmq.POLLIN)
+					poller.Add(backend, zmq.POLLIN)
+				}
+
+				if !appconfig.SameSocket(newBackendSC, backendSC) {
+					oldBackend := backend
+					drainBackend(oldBackend, b)
+					if err := oldBackend.Close(); err != nil {
+						logger.Warn("erro ao fechar backend antigo", "err", err)
+					}
+
+					newBackend, err := newRouterSocket(newBackendSC, "ROUTER", tuning, *curveKeysDir, false, logger)
+					if err != nil {
+						logger.Error("erro ao rebindar backend, tentando restaurar a config anterior", "err", err)
+						if restored, rerr := newRouterSocket(backendSC, "ROUTER", tuning, *curveKeysDir, false, logger); rerr != nil {
+							log.Fatal("Erro ao restaurar o backend apos falha no rebind:", rerr)
+						} else {
+							newBackend = restored
+						}
+					} else {
+						backendSC = newBackendSC
+						logger.Info("backend rebindado", "bind", backendSC.Bind, "connect", backendSC.Connect)
+					}
+					backend = newBackend
+					b.backend = backend
+					poller = zmq.NewPoller()
+					poller.Add(frontend, zmq.POLLIN)
+					poller.Add(backend, zmq.POLLIN)
+				}
+			default:
+			}
+		}
+
+		sockets, err := poller.Poll(heartbeatInterval)
+		if err != nil {
+			log.Fatal("Erro no poll:", err)
+		}
+
+		for _, s := range sockets {
+			switch s.Socket {
+			case frontend:
+				msg, err := frontend.RecvMessage(0)
+				if err != nil {
+					logger.Warn("erro ao receber do frontend", "err", err)
+					continue
+				}
+				// msg: [clientID, empty, "MDPC01", service, ...request]
+				if len(msg) < 4 || msg[2] != mdpClient {
+					logger.Warn("frame de cliente malformado, ignorando")
+					continue
+				}
+				b.handleClientFrame(msg[0], msg[3], msg[4:])
+
+			case backend:
+				msg, err := backend.RecvMessage(0)
+				if err != nil {
+					logger.Warn("erro ao receber do backend", "err", err)
+					continue
+				}
+				// msg: [workerID, empty, "MDPW01", command, ...]
+				if len(msg) < 4 || msg[2] != mdpWorker {
+					logger.Warn("frame de worker malformado, ignorando")
+					continue
+				}
+				b.handleWorkerFrame(msg[0], msg[3], msg[4:])
+			}
+		}
+
+		if time.Since(lastHeartbeat) >= heartbeatInterval {
+			b.sendHeartbeats()
+			b.purgeExpiredWorkers()
+			lastHeartbeat = time.Now()
+		}
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// fakeSocket grava os frames enviados por SendMessage, para testar o roteamento
+// do broker sem precisar de sockets ZMQ reais. sendErr, quando setado, e
+// devolvido por toda chamada a SendMessage sem gravar o frame.
+type fakeSocket struct {
+	sent    [][]interface{}
+	sendErr error
+}
+
+func (s *fakeSocket) SendMessage(parts ...interface{}) (int, error) {
+	if s.sendErr != nil {
+		return 0, s.sendErr
+	}
+	s.sent = append(s.sent, parts)
+	return len(parts), nil
+}
+
+func testBroker() (*broker, *fakeSocket, *fakeSocket) {
+	return testBrokerWithLog(&bytes.Buffer{})
+}
+
+func testBrokerWithLog(logOut *bytes.Buffer) (*broker, *fakeSocket, *fakeSocket) {
+	frontend := &fakeSocket{}
+	backend := &fakeSocket{}
+	b := newBroker(frontend, backend, nil, slog.New(slog.NewTextHandler(logOut, nil)))
+	return b, frontend, backend
+}
+
+func TestDispatchPairsRequestWithWaitingWorker(t *testing.T) {
+	b, _, backend := testBroker()
+	svc := b.serviceFor("echo")
+	w := &worker{identity: "worker-1", service: svc}
+	b.workers[w.identity] = w
+	svc.waiting.PushBack(w)
+
+	req := &pendingRequest{service: svc, clientID: "client-1", frames: [][]byte{[]byte("ping")}}
+	svc.requests.PushBack(req)
+
+	b.dispatch(svc)
+
+	if svc.requests.Len() != 0 || svc.waiting.Len() != 0 {
+		t.Fatalf("esperava fila e waiting list vazias apos dispatch, got requests=%d waiting=%d",
+			svc.requests.Len(), svc.waiting.Len())
+	}
+	if w.inFlight != req {
+		t.Fatalf("esperava que o worker ficasse com a requisicao em voo")
+	}
+	if len(backend.sent) != 1 {
+		t.Fatalf("esperava 1 frame enviado ao backend, got %d", len(backend.sent))
+	}
+}
+
+func TestRequeueRetriesBeforeFailingClient(t *testing.T) {
+	b, frontend, _ := testBroker()
+	svc := b.serviceFor("echo")
+	req := &pendingRequest{service: svc, clientID: "client-1", frames: [][]byte{[]byte("ping")}}
+
+	for i := 0; i < maxRetries; i++ {
+		b.requeue(svc, req)
+		if svc.requests.Len() != 1 {
+			t.Fatalf("tentativa %d: esperava requisicao de volta na fila, got len=%d", i, svc.requests.Len())
+		}
+		svc.requests.Remove(svc.requests.Front())
+	}
+	if len(frontend.sent) != 0 {
+		t.Fatalf("nao esperava resposta ao cliente antes de esgotar maxRetries")
+	}
+
+	b.requeue(svc, req)
+	if svc.requests.Len() != 0 {
+		t.Fatalf("esperava fila vazia apos esgotar maxRetries, got len=%d", svc.requests.Len())
+	}
+	if len(frontend.sent) != 1 {
+		t.Fatalf("esperava 1 resposta de erro ao cliente apos esgotar maxRetries, got %d", len(frontend.sent))
+	}
+}
+
+func TestRemoveWorkerRequeuesInFlightRequest(t *testing.T) {
+	b, _, backend := testBroker()
+	svc := b.serviceFor("echo")
+	w := &worker{identity: "worker-1", service: svc}
+	b.workers[w.identity] = w
+	svc.waiting.PushBack(w)
+
+	req := &pendingRequest{service: svc, clientID: "client-1", frames: [][]byte{[]byte("ping")}}
+	w.inFlight = req
+
+	b.removeWorker(w)
+
+	if _, ok := b.workers[w.identity]; ok {
+		t.Fatalf("esperava que o worker fosse removido de b.workers")
+	}
+	if svc.waiting.Len() != 0 {
+		t.Fatalf("esperava que o worker fosse removido da waiting list")
+	}
+	if svc.requests.Len() != 1 {
+		t.Fatalf("esperava a requisicao em voo reenfileirada, got len=%d", svc.requests.Len())
+	}
+	if len(backend.sent) != 0 {
+		t.Fatalf("sem outro worker disponivel, dispatch nao deveria enviar nada ao backend")
+	}
+}
+
+// TestSendToFrontendLogsDisconnectAsWarnOnEHostUnreach cobre a regressao em
+// que err == syscall.EHOSTUNREACH nunca batia: zmq4 devolve seus proprios
+// erros como zmq4.Errno (nao syscall.Errno), entao a comparacao precisa ser
+// contra zmq.EHOSTUNREACH.
+func TestSendToFrontendLogsDisconnectAsWarnOnEHostUnreach(t *testing.T) {
+	var logOut bytes.Buffer
+	b, frontend, _ := testBrokerWithLog(&logOut)
+	frontend.sendErr = zmq.EHOSTUNREACH
+
+	b.sendToFrontend([][]byte{[]byte("client-1"), nil})
+
+	out := logOut.String()
+	if !strings.Contains(out, "cliente desconectado") {
+		t.Fatalf("esperava o log de cliente desconectado (nivel warn), got %q", out)
+	}
+	if strings.Contains(out, "level=ERROR") {
+		t.Fatalf("EHOSTUNREACH nao deveria ser logado como erro inesperado, got %q", out)
+	}
+}
+
+func TestSendToFrontendLogsGenericErrorAsError(t *testing.T) {
+	var logOut bytes.Buffer
+	b, frontend, _ := testBrokerWithLog(&logOut)
+	frontend.sendErr = zmq.ETIMEDOUT
+
+	b.sendToFrontend([][]byte{[]byte("client-1"), nil})
+
+	out := logOut.String()
+	if !strings.Contains(out, "erro ao enviar frames pelo frontend") {
+		t.Fatalf("esperava o log generico de erro, got %q", out)
+	}
+}
+
+func TestPurgeExpiredWorkersRemovesStaleWorkers(t *testing.T) {
+	b, _, _ := testBroker()
+	svc := b.serviceFor("echo")
+
+	expired := &worker{identity: "worker-expired", service: svc, expiry: time.Now().Add(-time.Second)}
+	alive := &worker{identity: "worker-alive", service: svc, expiry: time.Now().Add(time.Minute)}
+	b.workers[expired.identity] = expired
+	b.workers[alive.identity] = alive
+	svc.waiting.PushBack(expired)
+	svc.waiting.PushBack(alive)
+
+	b.purgeExpiredWorkers()
+
+	if _, ok := b.workers[expired.identity]; ok {
+		t.Fatalf("esperava que o worker expirado fosse removido")
+	}
+	if _, ok := b.workers[alive.identity]; !ok {
+		t.Fatalf("nao esperava que o worker vivo fosse removido")
+	}
+}
@@ -0,0 +1,39 @@
+// Comando keygen gera um par de chaves CurveZMQ (Z85) e grava server.key /
+// server.key_secret no diretorio informado, no formato esperado por
+// zmqutil.EnableCurveServer (--curve-keys).
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+func main() {
+	outDir := flag.String("out", ".", "diretorio onde gravar server.key e server.key_secret")
+	flag.Parse()
+
+	public, secret, err := zmq.NewCurveKeypair()
+	if err != nil {
+		log.Fatal("Erro ao gerar par de chaves CurveZMQ:", err)
+	}
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		log.Fatal("Erro ao criar diretorio de saida:", err)
+	}
+
+	writeKeyFile(filepath.Join(*outDir, "server.key"), public)
+	writeKeyFile(filepath.Join(*outDir, "server.key_secret"), secret)
+
+	log.Printf("Chaves geradas em %s (server.key / server.key_secret)", *outDir)
+	log.Println("Adicione a chave publica de cada cliente confiavel a authorized_keys.txt nesse mesmo diretorio")
+}
+
+func writeKeyFile(path, key string) {
+	if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+		log.Fatalf("Erro ao gravar %s: %v", path, err)
+	}
+}
@@ -0,0 +1,293 @@
+// Comando monitor conecta aos capture taps do broker e do proxy pub/sub e agrega
+// estatisticas de trafego (contagem por servico/topico, tamanho de mensagens,
+// latencia request/reply) sem interferir no caminho de dados - e o outro lado
+// do "listener_thread" que broker.go e proxy.go ja alimentam via
+// zmqutil.StartCaptureTap().
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// mdpRequestCmd e o comando MDPW01 REQUEST (broker.go: mdpRequest) visto no
+// frame que o broker manda ao worker - usado aqui so para achar o clientID e
+// marcar o inicio da requisicao, ja que o monitor nao tem acesso ao estado
+// interno do broker, so ao que passa pelo capture tap.
+const mdpRequestCmd = "\x02"
+
+// latencyBucketsMs sao os limites superiores (em ms) do histograma de latencia
+// request/reply. O broker ja expoe essa mesma latencia via Prometheus
+// (metrics.ForwardLatency, chunk0-7) - este histograma existe para que o
+// monitor, que so enxerga o capture tap, consiga reportar a mesma coisa sem
+// depender do /metrics do broker.
+var latencyBucketsMs = []int64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// latencyHistogram acumula observacoes de latencia em buckets fixos, no mesmo
+// espirito de um histograma Prometheus, mas calculado localmente a partir da
+// correlacao de frames capturados.
+type latencyHistogram struct {
+	counts   []int // counts[i] = observacoes com latencia <= latencyBucketsMs[i] e > o bucket anterior
+	overflow int   // observacoes acima do maior bucket
+	count    int
+	sum      time.Duration
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]int, len(latencyBucketsMs))}
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	h.count++
+	h.sum += d
+	ms := d.Milliseconds()
+	for i, upper := range latencyBucketsMs {
+		if ms <= upper {
+			h.counts[i]++
+			return
+		}
+	}
+	h.overflow++
+}
+
+// summary formata o histograma como media + contagem acumulada por bucket,
+// para leitura direta no log sem precisar de um dashboard.
+func (h *latencyHistogram) summary() string {
+	if h.count == 0 {
+		return "sem amostras"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d amostras, media %s", h.count, h.sum/time.Duration(h.count))
+	cumulative := 0
+	for i, upper := range latencyBucketsMs {
+		cumulative += h.counts[i]
+		fmt.Fprintf(&b, ", <=%dms: %d", upper, cumulative)
+	}
+	if h.overflow > 0 {
+		fmt.Fprintf(&b, ", >%dms: %d", latencyBucketsMs[len(latencyBucketsMs)-1], h.overflow)
+	}
+	return b.String()
+}
+
+// stats acumula contadores simples por fonte, reiniciados a cada relatorio.
+type stats struct {
+	frames    int
+	bytes     int
+	byService map[string]int
+	byTopic   map[string]int
+	latency   map[string]*latencyHistogram // servico -> histograma de latencia request/reply
+}
+
+func newStats() *stats {
+	return &stats{
+		byService: make(map[string]int),
+		byTopic:   make(map[string]int),
+		latency:   make(map[string]*latencyHistogram),
+	}
+}
+
+func (s *stats) latencyFor(service string) *latencyHistogram {
+	h, ok := s.latency[service]
+	if !ok {
+		h = newLatencyHistogram()
+		s.latency[service] = h
+	}
+	return h
+}
+
+// record classifica um frame capturado. O envelope MDPW01 (broker->worker)
+// carrega o comando logo apos o header, nao o nome do servico - so o envelope
+// MDPC01 (broker->cliente, REPLY/ERROR) tem o servico na posicao esperada por
+// mdpReplyService, entao so ele conta para byService/latency; MDPW01 so serve
+// para abrir o par pendente em requestKey. Caso contrario assume o primeiro
+// frame como topico pub/sub (convencao XPUB/XSUB). pending sobrevive a resets
+// de stats entre relatorios - uma requisicao lenta nao pode perder o par so
+// porque o intervalo de relatorio virou no meio do caminho.
+func (s *stats) record(frames [][]byte, pending map[string]time.Time) {
+	s.frames++
+	for _, f := range frames {
+		s.bytes += len(f)
+	}
+
+	if clientID, ok := requestKey(frames); ok {
+		pending[clientID] = time.Now()
+	}
+
+	if service, ok := mdpReplyService(frames); ok {
+		s.byService[service]++
+		if clientID, ok := replyKey(frames); ok {
+			if start, ok := pending[clientID]; ok {
+				s.latencyFor(service).observe(time.Since(start))
+				delete(pending, clientID)
+			}
+		}
+		return
+	}
+
+	if isMDPFrame(frames) {
+		return
+	}
+
+	if len(frames) > 0 {
+		s.byTopic[string(frames[0])]++
+	}
+}
+
+func isMDPFrame(frames [][]byte) bool {
+	for _, f := range frames {
+		switch string(f) {
+		case "MDPC01", "MDPW01":
+			return true
+		}
+	}
+	return false
+}
+
+// mdpReplyService extrai o nome do servico de um envelope MDPC01 (broker->cliente,
+// REPLY ou ERROR: clientID, vazio, "MDPC01", servico, ...). So esse envelope tem o
+// servico nessa posicao - o MDPW01 (broker->worker) tem o comando ali, nao um nome
+// de servico, entao nao deve ser passado para esta funcao.
+func mdpReplyService(frames [][]byte) (string, bool) {
+	for i, f := range frames {
+		if string(f) == "MDPC01" && i+1 < len(frames) {
+			return string(frames[i+1]), true
+		}
+	}
+	return "", false
+}
+
+// requestKey extrai o clientID de um frame de requisicao broker->worker
+// (envelope MDPW01 com comando REQUEST: workerID, vazio, "MDPW01", REQUEST,
+// clientID, vazio, ...pedido), visto no capture tap quando o broker despacha
+// para um worker.
+func requestKey(frames [][]byte) (string, bool) {
+	for i, f := range frames {
+		if string(f) == "MDPW01" && i+2 < len(frames) && string(frames[i+1]) == mdpRequestCmd {
+			return string(frames[i+2]), true
+		}
+	}
+	return "", false
+}
+
+// replyKey extrai o clientID de um frame de resposta broker->cliente (envelope
+// MDPC01: clientID, vazio, "MDPC01", servico, ...resposta), para casar com o
+// pending registrado por requestKey.
+func replyKey(frames [][]byte) (string, bool) {
+	for i, f := range frames {
+		if string(f) == "MDPC01" && i >= 2 {
+			return string(frames[i-2]), true
+		}
+	}
+	return "", false
+}
+
+func (s *stats) report(source string) {
+	log.Printf("[%s] %d frames, %d bytes", source, s.frames, s.bytes)
+	for name, n := range s.byService {
+		log.Printf("[%s]   servico %q: %d requisicoes/respostas", source, name, n)
+	}
+	for topic, n := range s.byTopic {
+		log.Printf("[%s]   topico %q: %d publicacoes", source, topic, n)
+	}
+	for name, h := range s.latency {
+		log.Printf("[%s]   servico %q: latencia %s", source, name, h.summary())
+	}
+}
+
+// watch conecta a um capture tap (PUB) e alimenta um stats agregado a cada intervalo.
+func watch(addr, label string, interval time.Duration) {
+	sub, err := zmq.NewSocket(zmq.SUB)
+	if err != nil {
+		log.Fatalf("[%s] Erro ao criar socket SUB: %v", label, err)
+	}
+	defer sub.Close()
+
+	if err := sub.Connect(addr); err != nil {
+		log.Fatalf("[%s] Erro ao conectar em %s: %v", label, addr, err)
+	}
+	if err := sub.SetSubscribe(""); err != nil {
+		log.Fatalf("[%s] Erro ao assinar todos os topicos: %v", label, err)
+	}
+	log.Printf("[%s] Observando capture tap em %s", label, addr)
+
+	poller := zmq.NewPoller()
+	poller.Add(sub, zmq.POLLIN)
+
+	s := newStats()
+	lastReport := time.Now()
+	pending := make(map[string]time.Time)
+
+	for {
+		if _, err := poller.Poll(interval); err != nil {
+			log.Printf("[%s] Erro no poll: %v", label, err)
+			continue
+		}
+
+		for {
+			frames, err := sub.RecvMessageBytes(zmq.DONTWAIT)
+			if err != nil {
+				break
+			}
+			s.record(frames, pending)
+		}
+
+		if time.Since(lastReport) >= interval {
+			s.report(label)
+			s = newStats()
+			lastReport = time.Now()
+			prunePending(pending, pendingMaxAge)
+		}
+	}
+}
+
+// pendingMaxAge limita por quanto tempo uma requisicao sem resposta fica em
+// pending antes de ser descartada por prunePending - sem isso, um worker que
+// cai para sempre (ou um reinicio do broker no meio de uma requisicao em
+// voo) vazaria memoria em watch() a cada clientID que nunca fecha o par.
+const pendingMaxAge = 5 * time.Minute
+
+// prunePending remove de pending as requisicoes iniciadas ha mais de maxAge,
+// chamado a cada relatorio para nao deixar pending crescer sem limite quando
+// a resposta correspondente nunca chega pelo capture tap.
+func prunePending(pending map[string]time.Time, maxAge time.Duration) {
+	now := time.Now()
+	for clientID, start := range pending {
+		if now.Sub(start) > maxAge {
+			delete(pending, clientID)
+		}
+	}
+}
+
+// target e um capture tap a observar, identificado por um rotulo curto usado nos logs.
+type target struct {
+	addr  string
+	label string
+}
+
+func main() {
+	brokerAddr := flag.String("broker", "tcp://localhost:5559", "endereco do capture tap do broker")
+	proxyAddr := flag.String("proxy", "tcp://localhost:5560", "endereco do capture tap do proxy pub/sub")
+	interval := flag.Duration("interval", 5*time.Second, "intervalo entre relatorios agregados")
+	flag.Parse()
+
+	log.Println("Iniciando cmd/monitor...")
+
+	var targets []target
+	if strings.TrimSpace(*brokerAddr) != "" {
+		targets = append(targets, target{*brokerAddr, "broker"})
+	}
+	if strings.TrimSpace(*proxyAddr) != "" {
+		targets = append(targets, target{*proxyAddr, "proxy"})
+	}
+
+	done := make(chan struct{})
+	for _, t := range targets {
+		go watch(t.addr, t.label, *interval)
+	}
+	<-done
+}
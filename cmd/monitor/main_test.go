@@ -0,0 +1,137 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsMDPFrame(t *testing.T) {
+	if !isMDPFrame([][]byte{[]byte("c1"), []byte(""), []byte("MDPC01")}) {
+		t.Fatal("esperava reconhecer envelope MDPC01")
+	}
+	if !isMDPFrame([][]byte{[]byte("w1"), []byte(""), []byte("MDPW01")}) {
+		t.Fatal("esperava reconhecer envelope MDPW01")
+	}
+	if isMDPFrame([][]byte{[]byte("game.state"), []byte("v1")}) {
+		t.Fatal("nao esperava reconhecer frame pub/sub como MDP")
+	}
+}
+
+func TestMdpReplyService(t *testing.T) {
+	frames := [][]byte{[]byte("c1"), []byte(""), []byte("MDPC01"), []byte("echo"), []byte("payload")}
+	service, ok := mdpReplyService(frames)
+	if !ok || service != "echo" {
+		t.Fatalf("esperava servico %q, got %q (ok=%v)", "echo", service, ok)
+	}
+
+	if _, ok := mdpReplyService([][]byte{[]byte("sem-envelope")}); ok {
+		t.Fatal("nao esperava encontrar servico sem envelope MDPC01")
+	}
+
+	worker := [][]byte{[]byte("worker1"), []byte(""), []byte("MDPW01"), []byte(mdpRequestCmd), []byte("client1"), []byte(""), []byte("payload")}
+	if _, ok := mdpReplyService(worker); ok {
+		t.Fatal("nao esperava extrair servico de um envelope MDPW01 (carrega o comando, nao o servico, nessa posicao)")
+	}
+}
+
+func TestRequestKeyFindsClientIDOnWorkerRequest(t *testing.T) {
+	frames := [][]byte{[]byte("worker1"), []byte(""), []byte("MDPW01"), []byte(mdpRequestCmd), []byte("client1"), []byte(""), []byte("payload")}
+	clientID, ok := requestKey(frames)
+	if !ok {
+		t.Fatal("esperava encontrar clientID no frame de requisicao")
+	}
+	if clientID != "client1" {
+		t.Fatalf("esperava clientID %q, got %q", "client1", clientID)
+	}
+
+	if _, ok := requestKey([][]byte{[]byte("client1"), []byte(""), []byte("MDPC01"), []byte("echo")}); ok {
+		t.Fatal("nao esperava requestKey casar com um frame de resposta")
+	}
+}
+
+func TestReplyKeyFindsClientIDOnClientReply(t *testing.T) {
+	frames := [][]byte{[]byte("client1"), []byte(""), []byte("MDPC01"), []byte("echo"), []byte("payload")}
+	clientID, ok := replyKey(frames)
+	if !ok {
+		t.Fatal("esperava encontrar clientID no frame de resposta")
+	}
+	if clientID != "client1" {
+		t.Fatalf("esperava clientID %q, got %q", "client1", clientID)
+	}
+}
+
+func TestLatencyHistogramObserveAndSummary(t *testing.T) {
+	h := newLatencyHistogram()
+	if got := h.summary(); got != "sem amostras" {
+		t.Fatalf("esperava %q antes de qualquer observacao, got %q", "sem amostras", got)
+	}
+
+	h.observe(3 * time.Millisecond)
+	h.observe(6 * time.Second)
+
+	if h.count != 2 {
+		t.Fatalf("esperava 2 amostras, got %d", h.count)
+	}
+	if h.counts[0] != 1 {
+		t.Fatalf("esperava 1 amostra no primeiro bucket (<=5ms), got %d", h.counts[0])
+	}
+	if h.overflow != 1 {
+		t.Fatalf("esperava 1 amostra em overflow (>5000ms), got %d", h.overflow)
+	}
+}
+
+func TestStatsRecordCorrelatesRequestAndReplyLatency(t *testing.T) {
+	s := newStats()
+	pending := make(map[string]time.Time)
+
+	request := [][]byte{[]byte("worker1"), []byte(""), []byte("MDPW01"), []byte(mdpRequestCmd), []byte("client1"), []byte(""), []byte("payload")}
+	s.record(request, pending)
+
+	if len(pending) != 1 {
+		t.Fatalf("esperava 1 requisicao pendente apos o request, got %d", len(pending))
+	}
+
+	reply := [][]byte{[]byte("client1"), []byte(""), []byte("MDPC01"), []byte("echo"), []byte("resposta")}
+	s.record(reply, pending)
+
+	if len(pending) != 0 {
+		t.Fatalf("esperava que a resposta removesse a requisicao pendente, sobrou %d", len(pending))
+	}
+	if s.byService["echo"] != 1 {
+		t.Fatalf("esperava 1 contagem para o servico echo, got %d", s.byService["echo"])
+	}
+	h, ok := s.latency["echo"]
+	if !ok {
+		t.Fatal("esperava um histograma de latencia para o servico echo")
+	}
+	if h.count != 1 {
+		t.Fatalf("esperava 1 amostra de latencia, got %d", h.count)
+	}
+}
+
+func TestPrunePendingRemovesOnlyExpiredEntries(t *testing.T) {
+	pending := map[string]time.Time{
+		"stale": time.Now().Add(-10 * time.Minute),
+		"fresh": time.Now(),
+	}
+
+	prunePending(pending, 5*time.Minute)
+
+	if _, ok := pending["stale"]; ok {
+		t.Fatal("esperava que a entrada expirada fosse removida")
+	}
+	if _, ok := pending["fresh"]; !ok {
+		t.Fatal("nao esperava que a entrada recente fosse removida")
+	}
+}
+
+func TestStatsRecordCountsPubSubTopic(t *testing.T) {
+	s := newStats()
+	pending := make(map[string]time.Time)
+
+	s.record([][]byte{[]byte("game.state"), []byte("v1")}, pending)
+
+	if s.byTopic["game.state"] != 1 {
+		t.Fatalf("esperava 1 publicacao para o topico game.state, got %d", s.byTopic["game.state"])
+	}
+}
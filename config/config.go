@@ -0,0 +1,145 @@
+// Package config carrega a configuracao de bindings de socket do broker e do
+// proxy pub/sub a partir de um arquivo JSON, e observa esse arquivo para
+// permitir hot reload sem recompilar.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// SocketConfig descreve os enderecos e opcoes de um socket ZMQ. Endereco pode
+// ser repetido (ex.: "tcp://*:5555" e "ipc:///tmp/game.sock" ao mesmo tempo).
+type SocketConfig struct {
+	Type      string   `json:"type"` // ROUTER, DEALER, XSUB, XPUB, ...
+	Bind      []string `json:"bind,omitempty"`
+	Connect   []string `json:"connect,omitempty"`
+	HWM       int      `json:"hwm,omitempty"`
+	Identity  string   `json:"identity,omitempty"`
+	CurveKeys string   `json:"curve_keys,omitempty"`
+	Subscribe []string `json:"subscribe,omitempty"`
+}
+
+// Config e o documento completo de config.json: um socket nomeado por papel
+// logico ("frontend", "backend", "xsub", "xpub", ...) para cada programa.
+type Config struct {
+	Sockets map[string]SocketConfig `json:"sockets"`
+}
+
+// Socket retorna a configuracao do socket de nome logico informado.
+func (c *Config) Socket(name string) (SocketConfig, bool) {
+	if c == nil {
+		return SocketConfig{}, false
+	}
+	s, ok := c.Sockets[name]
+	return s, ok
+}
+
+// ResolveSocket resolve a config do socket de nome logico informado para um
+// programa (broker ou proxy). Quando cfg nao descreve esse socket (ou nao foi
+// carregado), ou descreve um sem nenhum bind/connect, cai para fallbackBind -
+// o comportamento de quando nao ha --config.
+func ResolveSocket(cfg *Config, name string, fallbackBind []string) SocketConfig {
+	sc, ok := cfg.Socket(name)
+	if !ok {
+		return SocketConfig{Bind: fallbackBind}
+	}
+	if len(sc.Bind) == 0 && len(sc.Connect) == 0 {
+		sc.Bind = fallbackBind
+	}
+	return sc
+}
+
+func sameAddrs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SameSocket compara os campos de SocketConfig que importam para decidir se
+// um socket precisa ser fechado e recriado durante o hot reload. Subscribe so
+// se aplica ao XSUB do proxy; comparar mesmo assim e inofensivo para o ROUTER
+// do broker, que nunca preenche esse campo.
+func SameSocket(a, b SocketConfig) bool {
+	return a.Type == b.Type &&
+		a.HWM == b.HWM &&
+		a.Identity == b.Identity &&
+		a.CurveKeys == b.CurveKeys &&
+		sameAddrs(a.Subscribe, b.Subscribe) &&
+		sameAddrs(a.Bind, b.Bind) &&
+		sameAddrs(a.Connect, b.Connect)
+}
+
+// Load le e decodifica o arquivo de config em path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: erro ao ler %s: %w", path, err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("config: erro ao decodificar %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Watch observa o diretorio de path com fsnotify e chama onChange com a
+// configuracao recarregada sempre que o arquivo e escrito. onChange roda na
+// goroutine do watcher - chamadores que mexem em sockets ZMQ (que nao sao
+// thread-safe) devem repassar a config para sua propria goroutine de loop
+// via canal em vez de agir diretamente aqui dentro.
+func Watch(path string, onChange func(*Config)) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("config: erro ao criar watcher: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("config: erro ao observar %s: %w", dir, err)
+	}
+
+	target := filepath.Clean(path)
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != target {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				cfg, err := Load(path)
+				if err != nil {
+					log.Println("config: erro ao recarregar apos mudanca:", err)
+					continue
+				}
+				onChange(cfg)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Println("config: erro do watcher:", err)
+			}
+		}
+	}()
+
+	return watcher, nil
+}
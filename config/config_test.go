@@ -0,0 +1,138 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSocketFallsBackWhenSocketMissing(t *testing.T) {
+	cfg := &Config{Sockets: map[string]SocketConfig{}}
+
+	sc := ResolveSocket(cfg, "frontend", []string{"tcp://*:5555"})
+
+	if len(sc.Bind) != 1 || sc.Bind[0] != "tcp://*:5555" {
+		t.Fatalf("esperava fallback bind, got %+v", sc)
+	}
+}
+
+func TestResolveSocketFallsBackWhenNoBindOrConnect(t *testing.T) {
+	cfg := &Config{Sockets: map[string]SocketConfig{
+		"frontend": {Type: "ROUTER", HWM: 42},
+	}}
+
+	sc := ResolveSocket(cfg, "frontend", []string{"tcp://*:5555"})
+
+	if sc.Type != "ROUTER" || sc.HWM != 42 {
+		t.Fatalf("esperava preservar os demais campos do config, got %+v", sc)
+	}
+	if len(sc.Bind) != 1 || sc.Bind[0] != "tcp://*:5555" {
+		t.Fatalf("esperava fallback bind quando config nao define bind nem connect, got %+v", sc)
+	}
+}
+
+func TestResolveSocketUsesConfigBindWhenPresent(t *testing.T) {
+	cfg := &Config{Sockets: map[string]SocketConfig{
+		"frontend": {Type: "ROUTER", Bind: []string{"tcp://*:6000"}},
+	}}
+
+	sc := ResolveSocket(cfg, "frontend", []string{"tcp://*:5555"})
+
+	if len(sc.Bind) != 1 || sc.Bind[0] != "tcp://*:6000" {
+		t.Fatalf("esperava o bind do config, got %+v", sc)
+	}
+}
+
+func TestResolveSocketUsesConfigConnectWithoutFallback(t *testing.T) {
+	cfg := &Config{Sockets: map[string]SocketConfig{
+		"frontend": {Type: "DEALER", Connect: []string{"tcp://peer:6000"}},
+	}}
+
+	sc := ResolveSocket(cfg, "frontend", []string{"tcp://*:5555"})
+
+	if len(sc.Bind) != 0 {
+		t.Fatalf("nao esperava fallback bind quando config define connect, got %+v", sc)
+	}
+	if len(sc.Connect) != 1 || sc.Connect[0] != "tcp://peer:6000" {
+		t.Fatalf("esperava o connect do config, got %+v", sc)
+	}
+}
+
+func TestSameSocket(t *testing.T) {
+	base := SocketConfig{
+		Type:      "XSUB",
+		Bind:      []string{"tcp://*:5557"},
+		Connect:   []string{"tcp://peer:5558"},
+		HWM:       100,
+		Identity:  "id1",
+		CurveKeys: "/keys",
+		Subscribe: []string{"game."},
+	}
+
+	same := base
+	if !SameSocket(base, same) {
+		t.Fatal("esperava que configs identicas fossem consideradas iguais")
+	}
+
+	cases := []struct {
+		name   string
+		modify func(sc SocketConfig) SocketConfig
+	}{
+		{"Type", func(sc SocketConfig) SocketConfig { sc.Type = "XPUB"; return sc }},
+		{"HWM", func(sc SocketConfig) SocketConfig { sc.HWM = 200; return sc }},
+		{"Identity", func(sc SocketConfig) SocketConfig { sc.Identity = "id2"; return sc }},
+		{"CurveKeys", func(sc SocketConfig) SocketConfig { sc.CurveKeys = "/other"; return sc }},
+		{"Subscribe", func(sc SocketConfig) SocketConfig { sc.Subscribe = []string{"chat."}; return sc }},
+		{"Bind", func(sc SocketConfig) SocketConfig { sc.Bind = []string{"tcp://*:9999"}; return sc }},
+		{"Connect", func(sc SocketConfig) SocketConfig { sc.Connect = []string{"tcp://other:9999"}; return sc }},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			other := c.modify(base)
+			if SameSocket(base, other) {
+				t.Fatalf("esperava que mudar %s tornasse os sockets diferentes", c.name)
+			}
+		})
+	}
+}
+
+func TestLoadParsesValidConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"sockets":{"frontend":{"type":"ROUTER","bind":["tcp://*:5555"],"hwm":100}}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("erro ao escrever config de teste: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("erro inesperado ao carregar config: %v", err)
+	}
+
+	sc, ok := cfg.Socket("frontend")
+	if !ok {
+		t.Fatal("esperava encontrar o socket 'frontend'")
+	}
+	if sc.Type != "ROUTER" || sc.HWM != 100 || len(sc.Bind) != 1 || sc.Bind[0] != "tcp://*:5555" {
+		t.Fatalf("config carregada nao bate com o esperado: %+v", sc)
+	}
+}
+
+func TestLoadReturnsErrorWhenFileMissing(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "does-not-exist.json"))
+	if err == nil {
+		t.Fatal("esperava erro ao carregar arquivo inexistente")
+	}
+}
+
+func TestLoadReturnsErrorOnInvalidJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte("{not valid json"), 0o644); err != nil {
+		t.Fatalf("erro ao escrever config de teste: %v", err)
+	}
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("esperava erro ao carregar JSON invalido")
+	}
+}
@@ -0,0 +1,14 @@
+// Package logging fornece o logger estruturado (JSON) usado pelo broker e
+// pelo proxy pub/sub em producao/containers, no lugar de log.Println cru.
+package logging
+
+import (
+	"log/slog"
+	"os"
+)
+
+// New cria um logger que emite um objeto JSON por linha em stdout, pronto
+// para ser coletado por um agente de log em k8s/Docker.
+func New() *slog.Logger {
+	return slog.New(slog.NewJSONHandler(os.Stdout, nil))
+}
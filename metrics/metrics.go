@@ -0,0 +1,67 @@
+// Package metrics expoe as metricas Prometheus do broker e do proxy pub/sub,
+// alimentadas pelo loop manual de poll que ja substituiu o zmq.Proxy opaco.
+package metrics
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// FramesForwarded conta frames repassados, por direcao (ex.: "client_to_worker")
+	// e socket (ex.: "frontend", "xsub").
+	FramesForwarded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "zmq_frames_forwarded_total",
+		Help: "Total de frames ZMQ repassados, por direcao e socket.",
+	}, []string{"direction", "socket"})
+
+	// BytesForwarded soma o tamanho em bytes dos frames repassados.
+	BytesForwarded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "zmq_bytes_forwarded_total",
+		Help: "Total de bytes ZMQ repassados, por direcao e socket.",
+	}, []string{"direction", "socket"})
+
+	// ActivePeers reflete quantos peers (workers MDP, assinantes conhecidos) um
+	// socket enxerga agora.
+	ActivePeers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "zmq_active_peers",
+		Help: "Numero de peers ativos conhecidos, por socket.",
+	}, []string{"socket"})
+
+	// ForwardLatency mede o tempo entre uma requisicao de cliente entrar na fila
+	// do broker e a resposta correspondente ser repassada de volta.
+	ForwardLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "zmq_forward_latency_seconds",
+		Help:    "Latencia entre requisicao enfileirada e resposta repassada, por servico.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service"})
+
+	// ServiceRequests conta requisicoes roteadas pelo broker, por nome de servico.
+	ServiceRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "zmq_service_requests_total",
+		Help: "Total de requisicoes MDP roteadas, por servico.",
+	}, []string{"service"})
+
+	// TopicPublications conta publicacoes repassadas pelo proxy pub/sub, por topico.
+	TopicPublications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "zmq_topic_publications_total",
+		Help: "Total de publicacoes pub/sub repassadas, por topico.",
+	}, []string{"topic"})
+)
+
+// StartServer sobe um servidor HTTP em background expondo /metrics em addr.
+// Um erro de bind e fatal: observabilidade quebrada em silencio e pior do que
+// o processo nao subir.
+func StartServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Fatal("metrics: erro ao subir servidor HTTP em ", addr, ": ", err)
+		}
+	}()
+}
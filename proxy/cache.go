@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultCacheMaxEntries e defaultCacheTTL sao os valores usados quando
+// --cache-max-entries/--cache-ttl (e as env vars CACHE_MAX_ENTRIES/CACHE_TTL_MS)
+// nao sao informados, preservando o limite fixo de antes desses flags existirem.
+const (
+	defaultCacheMaxEntries = 1000
+	defaultCacheTTL        = 30 * time.Second
+)
+
+func envCacheMaxEntries(def int) int {
+	v, ok := os.LookupEnv("CACHE_MAX_ENTRIES")
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func envCacheTTL(def time.Duration) time.Duration {
+	v, ok := os.LookupEnv("CACHE_TTL_MS")
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+	return time.Duration(n) * time.Millisecond
+}
+
+// cacheEntry guarda a ultima mensagem publicada em um topico e quando chegou.
+type cacheEntry struct {
+	frames   [][]byte
+	cachedAt time.Time
+}
+
+// lastValueCache mantem a mensagem mais recente por topico, para replay no
+// momento em que alguem assina aquele topico (ou um prefixo dele).
+//
+// Atencao: XPUB/PUB sao sockets topic-filtered broadcast - nao existe um jeito
+// de enderecar o replay so para quem acabou de assinar. O replay sai pelo XPUB
+// normalmente e e entregue a TODO assinante atual cujo filtro bate com o
+// topico, nao so ao late joiner. Na pratica, toda vez que um bot (re)assina um
+// topico, os demais assinantes daquele topico recebem a ultima mensagem de
+// novo. Ver uso em proxy.go, case xpub.
+type lastValueCache struct {
+	entries    map[string]cacheEntry
+	order      []string // ordem de insercao/atualizacao, para evictar o mais antigo
+	maxEntries int
+	ttl        time.Duration
+}
+
+// newLastValueCache recebe maxEntries/ttl em vez de ler os defaults diretamente,
+// para que --cache-max-entries/--cache-ttl (ou as env vars equivalentes) de
+// main() sejam a unica fonte da verdade do limite.
+func newLastValueCache(maxEntries int, ttl time.Duration) *lastValueCache {
+	return &lastValueCache{entries: make(map[string]cacheEntry), maxEntries: maxEntries, ttl: ttl}
+}
+
+// put armazena a ultima mensagem de um topico, evictando a entrada mais antiga
+// se o cache estiver cheio.
+func (c *lastValueCache) put(topic string, frames [][]byte) {
+	if _, exists := c.entries[topic]; !exists {
+		if len(c.entries) >= c.maxEntries {
+			c.evictOldest()
+		}
+		c.order = append(c.order, topic)
+	}
+	c.entries[topic] = cacheEntry{frames: frames, cachedAt: time.Now()}
+}
+
+func (c *lastValueCache) evictOldest() {
+	for len(c.order) > 0 {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		if _, ok := c.entries[oldest]; ok {
+			delete(c.entries, oldest)
+			return
+		}
+	}
+}
+
+// matching retorna, em qualquer ordem, os frames de todo topico cujo prefixo
+// bate com o informado e que ainda nao expirou segundo c.ttl. Quem chama isto
+// para fazer replay deve lembrar que o envio e um broadcast (ver o aviso
+// no doc de lastValueCache), nao um unicast para um assinante especifico.
+func (c *lastValueCache) matching(prefix string) [][][]byte {
+	var result [][][]byte
+	now := time.Now()
+	for topic, entry := range c.entries {
+		if now.Sub(entry.cachedAt) > c.ttl {
+			continue
+		}
+		if bytes.HasPrefix([]byte(topic), []byte(prefix)) {
+			result = append(result, entry.frames)
+		}
+	}
+	return result
+}
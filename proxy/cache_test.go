@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestLastValueCachePutAndMatching(t *testing.T) {
+	c := newLastValueCache(defaultCacheMaxEntries, defaultCacheTTL)
+	c.put("game.state", [][]byte{[]byte("game.state"), []byte("v1")})
+	c.put("game.score", [][]byte{[]byte("game.score"), []byte("10")})
+	c.put("chat.lobby", [][]byte{[]byte("chat.lobby"), []byte("oi")})
+
+	matches := c.matching("game.")
+	if len(matches) != 2 {
+		t.Fatalf("esperava 2 entradas com prefixo 'game.', got %d", len(matches))
+	}
+}
+
+func TestLastValueCachePutOverwritesSameTopic(t *testing.T) {
+	c := newLastValueCache(defaultCacheMaxEntries, defaultCacheTTL)
+	c.put("game.state", [][]byte{[]byte("game.state"), []byte("v1")})
+	c.put("game.state", [][]byte{[]byte("game.state"), []byte("v2")})
+
+	matches := c.matching("game.state")
+	if len(matches) != 1 {
+		t.Fatalf("esperava 1 entrada para o topico, got %d", len(matches))
+	}
+	if string(matches[0][1]) != "v2" {
+		t.Fatalf("esperava que a segunda publicacao tivesse sobrescrito a primeira, got %q", matches[0][1])
+	}
+}
+
+func TestLastValueCacheEvictsOldestWhenFull(t *testing.T) {
+	c := newLastValueCache(defaultCacheMaxEntries, defaultCacheTTL)
+	for i := 0; i < defaultCacheMaxEntries; i++ {
+		topic := string(rune('a' + i%26))
+		c.put(topic+string(rune(i)), [][]byte{[]byte("x")})
+	}
+	if len(c.entries) != defaultCacheMaxEntries {
+		t.Fatalf("esperava o cache cheio em %d entradas, got %d", defaultCacheMaxEntries, len(c.entries))
+	}
+
+	c.put("one-more-topic", [][]byte{[]byte("x")})
+	if len(c.entries) != defaultCacheMaxEntries {
+		t.Fatalf("esperava que o cache continuasse com no maximo %d entradas apos evict, got %d",
+			defaultCacheMaxEntries, len(c.entries))
+	}
+}
+
+func TestLastValueCacheRespectsCustomMaxEntries(t *testing.T) {
+	c := newLastValueCache(2, defaultCacheTTL)
+	c.put("a", [][]byte{[]byte("x")})
+	c.put("b", [][]byte{[]byte("x")})
+	c.put("c", [][]byte{[]byte("x")})
+
+	if len(c.entries) != 2 {
+		t.Fatalf("esperava o limite customizado de 2 entradas, got %d", len(c.entries))
+	}
+}
@@ -1,44 +1,411 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"log/slog"
+	"time"
 
+	appconfig "github.com/lucasdias-03/sistemas-distribuidos-projeto/config"
+	applog "github.com/lucasdias-03/sistemas-distribuidos-projeto/logging"
+	appmetrics "github.com/lucasdias-03/sistemas-distribuidos-projeto/metrics"
+	"github.com/lucasdias-03/sistemas-distribuidos-projeto/zmqutil"
 	zmq "github.com/pebbe/zmq4"
 )
 
-func main() {
-	log.Println("Iniciando Proxy Pub/Sub...")
+// captureMirrorAddr e onde o capture tap e espelhado para fora do processo,
+// para que cmd/monitor possa observar o trafego sem acoplamento ao proxy.
+// Porta diferente da do broker (5559) para que ambos possam rodar na mesma maquina.
+const captureMirrorAddr = "tcp://*:5560"
 
-	// Socket XSUB para publishers (servidores)
-	xsub, err := zmq.NewSocket(zmq.XSUB)
+// forwarded registra no Prometheus um frame que acabou de ser repassado por um
+// socket, com seu tamanho total em bytes.
+func forwarded(direction, socket string, frames [][]byte) {
+	size := 0
+	for _, f := range frames {
+		size += len(f)
+	}
+	appmetrics.FramesForwarded.WithLabelValues(direction, socket).Inc()
+	appmetrics.BytesForwarded.WithLabelValues(direction, socket).Add(float64(size))
+}
+
+// defaultXsubAddrs e defaultXpubAddrs sao usados quando --config nao e
+// informado ou nao descreve aquele socket, preservando o comportamento de hoje.
+var (
+	defaultXsubAddrs = []string{"tcp://*:5557"}
+	defaultXpubAddrs = []string{"tcp://*:5558"}
+)
+
+// reloadPollInterval limita quanto tempo o poll espera quando o hot reload
+// esta ativo, para que o canal de config recarregada seja checado com frequencia.
+const reloadPollInterval = 1 * time.Second
+
+// applySocketOptions aplica os campos de sc comuns a XSUB e XPUB: tipo
+// declarado (so um aviso se destoar do esperado), curve, tuning/HWM, identity
+// e bind/connect. curveKeysDir e o --curve-keys global; sc.CurveKeys, quando
+// presente, tem prioridade para esse socket especifico.
+func applySocketOptions(sock *zmq.Socket, sc appconfig.SocketConfig, expectedType string,
+	tuning zmqutil.SocketTuning, curveKeysDir string, logger *slog.Logger) error {
+	if sc.Type != "" && sc.Type != expectedType {
+		logger.Warn("config.json declara um tipo de socket diferente do esperado",
+			"declared_type", sc.Type, "expected_type", expectedType)
+	}
+
+	keysDir := curveKeysDir
+	if sc.CurveKeys != "" {
+		keysDir = sc.CurveKeys
+	}
+	if keysDir != "" {
+		if err := zmqutil.EnableCurveServer(sock, keysDir); err != nil {
+			return err
+		}
+	}
+
+	t := tuning
+	if sc.HWM > 0 {
+		t.Sndhwm, t.Rcvhwm = sc.HWM, sc.HWM
+	}
+	if err := t.Apply(sock); err != nil {
+		return err
+	}
+
+	if sc.Identity != "" {
+		if err := sock.SetIdentity(sc.Identity); err != nil {
+			return err
+		}
+	}
+
+	for _, addr := range sc.Bind {
+		if err := sock.Bind(addr); err != nil {
+			return err
+		}
+	}
+	for _, addr := range sc.Connect {
+		if err := sock.Connect(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newXsubSocket(sc appconfig.SocketConfig, tuning zmqutil.SocketTuning, curveKeysDir string, logger *slog.Logger) (*zmq.Socket, error) {
+	sock, err := zmq.NewSocket(zmq.XSUB)
 	if err != nil {
-		log.Fatal("Erro ao criar socket XSUB:", err)
+		return nil, err
 	}
-	defer xsub.Close()
+	if err := applySocketOptions(sock, sc, "XSUB", tuning, curveKeysDir, logger); err != nil {
+		sock.Close()
+		return nil, err
+	}
+	// Subscribe pre-assina o XSUB nos topicos informados junto ao publisher
+	// rio acima, independente de qualquer subscriber rio abaixo ja ter se
+	// conectado - util para publishers que so enviam sob demanda.
+	for _, filter := range sc.Subscribe {
+		if err := sock.SetSubscribe(filter); err != nil {
+			sock.Close()
+			return nil, err
+		}
+	}
+	return sock, nil
+}
+
+func newXpubSocket(sc appconfig.SocketConfig, tuning zmqutil.SocketTuning, curveKeysDir string, logger *slog.Logger) (*zmq.Socket, error) {
+	sock, err := zmq.NewSocket(zmq.XPUB)
+	if err != nil {
+		return nil, err
+	}
+	if err := applySocketOptions(sock, sc, "XPUB", tuning, curveKeysDir, logger); err != nil {
+		sock.Close()
+		return nil, err
+	}
+	// XPUB_VERBOSE repassa toda (un)subscription rio acima, mesmo repetidas -
+	// sem isso o zmq4 so notifica a primeira vez que um topico ganha assinante,
+	// o que esconde assinaturas de late joiners que precisamos ver para o replay.
+	if err := sock.SetXpubVerbose(1); err != nil {
+		sock.Close()
+		return nil, err
+	}
+	return sock, nil
+}
+
+func main() {
+	curveKeysDir := flag.String("curve-keys", "", "diretorio com server.key_secret e authorized_keys.txt "+
+		"(gerados por cmd/keygen) para habilitar CurveZMQ; vazio mantem o texto plano de hoje")
+	configPath := flag.String("config", "", "arquivo JSON com os bindings de socket (ver config.json); "+
+		"vazio usa as portas padrao 5557/5558 e desabilita o hot reload")
+	metricsAddr := flag.String("metrics-addr", ":9091", "endereco do servidor HTTP que expoe /metrics (Prometheus)")
+
+	tuning := zmqutil.DefaultTuning()
+	flag.IntVar(&tuning.Sndhwm, "sndhwm", tuning.Sndhwm, "ZMQ_SNDHWM do XSUB e do XPUB (env SNDHWM)")
+	flag.IntVar(&tuning.Rcvhwm, "rcvhwm", tuning.Rcvhwm, "ZMQ_RCVHWM do XSUB e do XPUB (env RCVHWM)")
+	flag.IntVar(&tuning.Sndbuf, "sndbuf", tuning.Sndbuf, "ZMQ_SNDBUF, 0 usa o default do SO (env SNDBUF)")
+	flag.IntVar(&tuning.Rcvbuf, "rcvbuf", tuning.Rcvbuf, "ZMQ_RCVBUF, 0 usa o default do SO (env RCVBUF)")
+	flag.DurationVar(&tuning.Linger, "linger", tuning.Linger, "ZMQ_LINGER ao fechar os sockets (env LINGER_MS)")
+	flag.BoolVar(&tuning.TcpKeepAlive, "tcp-keepalive", tuning.TcpKeepAlive, "ZMQ_TCP_KEEPALIVE (env TCP_KEEPALIVE)")
+
+	cacheMaxEntries := flag.Int("cache-max-entries", envCacheMaxEntries(defaultCacheMaxEntries),
+		"numero maximo de topicos mantidos no last-value cache, o mais antigo e evictado quando cheio (env CACHE_MAX_ENTRIES)")
+	cacheTTL := flag.Duration("cache-ttl", envCacheTTL(defaultCacheTTL),
+		"tempo que uma entrada do last-value cache fica valida para replay a late joiners (env CACHE_TTL_MS)")
+	flag.Parse()
 
-	err = xsub.Bind("tcp://*:5557")
+	if *cacheMaxEntries <= 0 {
+		log.Fatalf("--cache-max-entries precisa ser >= 1, recebido %d", *cacheMaxEntries)
+	}
+
+	logger := applog.New()
+	appmetrics.StartServer(*metricsAddr)
+	logger.Info("iniciando proxy pub/sub", "metrics_addr", *metricsAddr)
+
+	if *curveKeysDir != "" {
+		if err := zmqutil.StartCurveAuth(*curveKeysDir); err != nil {
+			log.Fatal("Erro ao iniciar autenticacao CurveZMQ:", err)
+		}
+		defer zmq.AuthStop()
+	}
+
+	var cfg *appconfig.Config
+	if *configPath != "" {
+		loaded, err := appconfig.Load(*configPath)
+		if err != nil {
+			log.Fatal("Erro ao carregar config:", err)
+		}
+		cfg = loaded
+	}
+
+	xsubSC := appconfig.ResolveSocket(cfg, "xsub", defaultXsubAddrs)
+	xpubSC := appconfig.ResolveSocket(cfg, "xpub", defaultXpubAddrs)
+
+	// Socket XSUB para publishers (servidores)
+	xsub, err := newXsubSocket(xsubSC, tuning, *curveKeysDir, logger)
 	if err != nil {
-		log.Fatal("Erro ao fazer bind no XSUB:", err)
+		log.Fatal("Erro ao preparar o XSUB:", err)
 	}
-	log.Println("XSUB escutando na porta 5557 (publishers/servidores)")
+	defer xsub.Close()
+	logger.Info("XSUB escutando (publishers/servidores)", "bind", xsubSC.Bind, "connect", xsubSC.Connect)
 
 	// Socket XPUB para subscribers (clientes/bots)
-	xpub, err := zmq.NewSocket(zmq.XPUB)
+	xpub, err := newXpubSocket(xpubSC, tuning, *curveKeysDir, logger)
 	if err != nil {
-		log.Fatal("Erro ao criar socket XPUB:", err)
+		log.Fatal("Erro ao preparar o XPUB:", err)
 	}
 	defer xpub.Close()
+	logger.Info("XPUB escutando (subscribers/clientes)", "bind", xpubSC.Bind, "connect", xpubSC.Connect)
 
-	err = xpub.Bind("tcp://*:5558")
-	if err != nil {
-		log.Fatal("Erro ao fazer bind no XPUB:", err)
+	// Capture tap opcional - espelha cada frame repassado para cmd/monitor.
+	capture := zmqutil.StartCaptureTap(captureMirrorAddr)
+	if capture != nil {
+		defer capture.Close()
+	}
+	mirror := func(frames [][]byte) {
+		if capture == nil {
+			return
+		}
+		if _, err := capture.SendMessage(frames); err != nil {
+			logger.Error("erro ao espelhar frames no capture tap", "err", err)
+		}
 	}
-	log.Println("XPUB escutando na porta 5558 (subscribers/clientes)")
 
-	// Iniciar proxy - repassa mensagens entre publishers e subscribers
-	log.Println("Proxy Pub/Sub rodando...")
-	err = zmq.Proxy(xsub, xpub, nil)
-	if err != nil {
-		log.Fatal("Erro no proxy:", err)
+	cache := newLastValueCache(*cacheMaxEntries, *cacheTTL)
+	subs := newSubscriptionTracker()
+
+	// handleXsubFrame repassa uma publicacao recebida do XSUB para o XPUB,
+	// alimentando cache/metrics/capture. Extraido do loop principal para que o
+	// drain do XSUB antigo durante um hot reload trate o que estava em voo da
+	// mesma forma, em vez de so descartar.
+	handleXsubFrame := func(frames [][]byte) {
+		if _, err := xpub.SendMessage(frames); err != nil {
+			logger.Error("erro ao repassar publicacao para o XPUB", "err", err)
+		}
+		forwarded("publisher_to_subscriber", "xpub", frames)
+		mirror(frames)
+		if len(frames) > 0 {
+			topic := string(frames[0])
+			cache.put(topic, frames)
+			appmetrics.TopicPublications.WithLabelValues(topic).Inc()
+		}
+	}
+
+	// handleXpubFrame e o analogo de handleXsubFrame para (un)subscriptions
+	// recebidas do XPUB, incluindo o replay de late joiner.
+	handleXpubFrame := func(frame []byte) {
+		if _, err := xsub.SendBytes(frame, 0); err != nil {
+			logger.Error("erro ao repassar (un)subscription para o XSUB", "err", err)
+		}
+		forwarded("subscriber_to_publisher", "xsub", [][]byte{frame})
+		mirror([][]byte{frame})
+
+		if len(frame) == 0 {
+			return
+		}
+		subscribe := frame[0] == 1
+		topic := string(frame[1:])
+		if subscribe {
+			subs.subscribe(topic)
+		} else {
+			subs.unsubscribe(topic)
+		}
+		appmetrics.ActivePeers.WithLabelValues("xpub").Set(float64(subs.total))
+		if !subscribe {
+			return
+		}
+		// Late joiner: repassa a ultima mensagem conhecida de cada topico
+		// que bate com o prefixo assinado. Isso sai pelo XPUB como
+		// broadcast normal, ou seja, vai para TODO assinante atual do
+		// topico, nao so para quem acabou de (re)assinar - XPUB/PUB nao
+		// tem como enderecar um unico peer. Efeito colateral aceito: bots
+		// ja inscritos recebem a mensagem de novo sempre que outro bot
+		// (re)assina o mesmo topico. Ver o aviso em cache.go.
+		for _, cached := range cache.matching(topic) {
+			if _, err := xpub.SendMessage(cached); err != nil {
+				logger.Error("erro ao repassar mensagem em cache (broadcast) apos nova assinatura", "err", err)
+			}
+			forwarded("publisher_to_subscriber", "xpub", cached)
+			mirror(cached)
+		}
+	}
+
+	// drainXsub/drainXpub esvaziam o que ja foi recebido (mas ainda nao lido)
+	// no socket antigo antes do Close durante um hot reload, processando cada
+	// frame como o loop principal faria. ZMQ_LINGER so cobre envio pendente no
+	// Close - mensagens que ja chegaram mas ainda nao foram lidas via
+	// RecvMessage sao descartadas na hora, entao precisam ser puxadas aqui antes.
+	drainXsub := func(sock *zmq.Socket) {
+		for {
+			frames, err := sock.RecvMessageBytes(zmq.DONTWAIT)
+			if err != nil {
+				return
+			}
+			handleXsubFrame(frames)
+		}
+	}
+	drainXpub := func(sock *zmq.Socket) {
+		for {
+			frame, err := sock.RecvBytes(zmq.DONTWAIT)
+			if err != nil {
+				return
+			}
+			handleXpubFrame(frame)
+		}
+	}
+
+	// Loop manual sobre XSUB/XPUB no lugar de zmq.Proxy: precisamos inspecionar
+	// cada frame de (un)subscription para alimentar e servir o last-value cache,
+	// o que o zmq.Proxy opaco nao permite.
+	poller := zmq.NewPoller()
+	poller.Add(xsub, zmq.POLLIN)
+	poller.Add(xpub, zmq.POLLIN)
+
+	// Hot reload: o watcher roda na sua propria goroutine e so manda a config
+	// recarregada por canal - quem de fato troca os sockets ZMQ (nao
+	// thread-safe) e sempre a goroutine principal, entre dois Polls.
+	var reloadCh chan *appconfig.Config
+	pollTimeout := time.Duration(-1)
+	if *configPath != "" {
+		reloadCh = make(chan *appconfig.Config, 1)
+		pollTimeout = reloadPollInterval
+		watcher, err := appconfig.Watch(*configPath, func(newCfg *appconfig.Config) {
+			select {
+			case reloadCh <- newCfg:
+			default:
+			}
+		})
+		if err != nil {
+			logger.Warn("hot reload desabilitado, erro ao observar config", "err", err)
+		} else {
+			defer watcher.Close()
+			logger.Info("observando config para hot reload dos bindings", "path", *configPath)
+		}
+	}
+
+	logger.Info("proxy pub/sub rodando (last-value cache para late joiners)")
+	for {
+		if reloadCh != nil {
+			select {
+			case newCfg := <-reloadCh:
+				newXsubSC := appconfig.ResolveSocket(newCfg, "xsub", defaultXsubAddrs)
+				newXpubSC := appconfig.ResolveSocket(newCfg, "xpub", defaultXpubAddrs)
+
+				// Fecha (apos drenar) o socket antigo antes de criar o novo: se so
+				// HWM/identity/curve_keys/subscribe mudaram, bind/connect continuam
+				// os mesmos, e o novo XSUB/XPUB nao consegue fazer bind no mesmo
+				// endereco enquanto o antigo ainda estiver escutando nele.
+				if !appconfig.SameSocket(newXsubSC, xsubSC) {
+					oldXsub := xsub
+					drainXsub(oldXsub)
+					if err := oldXsub.Close(); err != nil {
+						logger.Warn("erro ao fechar XSUB antigo", "err", err)
+					}
+
+					newXsub, err := newXsubSocket(newXsubSC, tuning, *curveKeysDir, logger)
+					if err != nil {
+						logger.Error("erro ao rebindar XSUB, tentando restaurar a config anterior", "err", err)
+						if restored, rerr := newXsubSocket(xsubSC, tuning, *curveKeysDir, logger); rerr != nil {
+							log.Fatal("Erro ao restaurar o XSUB apos falha no rebind:", rerr)
+						} else {
+							newXsub = restored
+						}
+					} else {
+						xsubSC = newXsubSC
+						logger.Info("XSUB rebindado", "bind", xsubSC.Bind, "connect", xsubSC.Connect)
+					}
+					xsub = newXsub
+					poller = zmq.NewPoller()
+					poller.Add(xsub, zmq.POLLIN)
+					poller.Add(xpub, zmq.POLLIN)
+				}
+
+				if !appconfig.SameSocket(newXpubSC, xpubSC) {
+					oldXpub := xpub
+					drainXpub(oldXpub)
+					if err := oldXpub.Close(); err != nil {
+						logger.Warn("erro ao fechar XPUB antigo", "err", err)
+					}
+
+					newXpub, err := newXpubSocket(newXpubSC, tuning, *curveKeysDir, logger)
+					if err != nil {
+						logger.Error("erro ao rebindar XPUB, tentando restaurar a config anterior", "err", err)
+						if restored, rerr := newXpubSocket(xpubSC, tuning, *curveKeysDir, logger); rerr != nil {
+							log.Fatal("Erro ao restaurar o XPUB apos falha no rebind:", rerr)
+						} else {
+							newXpub = restored
+						}
+					} else {
+						xpubSC = newXpubSC
+						logger.Info("XPUB rebindado", "bind", xpubSC.Bind, "connect", xpubSC.Connect)
+					}
+					xpub = newXpub
+					poller = zmq.NewPoller()
+					poller.Add(xsub, zmq.POLLIN)
+					poller.Add(xpub, zmq.POLLIN)
+				}
+			default:
+			}
+		}
+
+		sockets, err := poller.Poll(pollTimeout)
+		if err != nil {
+			log.Fatal("Erro no poll:", err)
+		}
+
+		for _, s := range sockets {
+			switch s.Socket {
+			case xsub:
+				frames, err := xsub.RecvMessageBytes(0)
+				if err != nil {
+					logger.Warn("erro ao receber do XSUB", "err", err)
+					continue
+				}
+				handleXsubFrame(frames)
+
+			case xpub:
+				frame, err := xpub.RecvBytes(0)
+				if err != nil {
+					logger.Warn("erro ao receber do XPUB", "err", err)
+					continue
+				}
+				handleXpubFrame(frame)
+			}
+		}
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,32 @@
+package main
+
+// subscriptionTracker conta quantas assinaturas ativas existem por topico, a
+// partir dos frames XPUB_VERBOSE de (un)subscription que passam pelo XPUB.
+// XSUB/XPUB nao expoe identidade de peer como um ROUTER faz - o que da para
+// saber e quantas assinaturas estao abertas agora, nao quantos bots
+// distintos, que e o melhor proxy disponivel para zmq_active_peers do lado
+// do proxy pub/sub.
+type subscriptionTracker struct {
+	counts map[string]int
+	total  int
+}
+
+func newSubscriptionTracker() *subscriptionTracker {
+	return &subscriptionTracker{counts: make(map[string]int)}
+}
+
+func (t *subscriptionTracker) subscribe(topic string) {
+	t.counts[topic]++
+	t.total++
+}
+
+func (t *subscriptionTracker) unsubscribe(topic string) {
+	if t.counts[topic] == 0 {
+		return
+	}
+	t.counts[topic]--
+	t.total--
+	if t.counts[topic] == 0 {
+		delete(t.counts, topic)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestSubscriptionTrackerCountsAcrossTopics(t *testing.T) {
+	s := newSubscriptionTracker()
+	s.subscribe("game.state")
+	s.subscribe("game.score")
+	s.subscribe("game.state")
+
+	if s.total != 3 {
+		t.Fatalf("esperava total 3, got %d", s.total)
+	}
+}
+
+func TestSubscriptionTrackerUnsubscribeDecrements(t *testing.T) {
+	s := newSubscriptionTracker()
+	s.subscribe("game.state")
+	s.subscribe("game.state")
+	s.unsubscribe("game.state")
+
+	if s.total != 1 {
+		t.Fatalf("esperava total 1, got %d", s.total)
+	}
+	if s.counts["game.state"] != 1 {
+		t.Fatalf("esperava contagem 1 para o topico, got %d", s.counts["game.state"])
+	}
+}
+
+func TestSubscriptionTrackerUnsubscribeIgnoredWhenNotTracked(t *testing.T) {
+	s := newSubscriptionTracker()
+	s.unsubscribe("never.subscribed")
+
+	if s.total != 0 {
+		t.Fatalf("esperava total 0, got %d", s.total)
+	}
+}
@@ -0,0 +1,78 @@
+// Package zmqutil reune o setup de socket ZMQ compartilhado entre o broker
+// e o proxy pub/sub (capture tap, CurveZMQ, tuning de HWM/buffer/linger), que
+// ate aqui vivia duplicado em broker/ e proxy/.
+package zmqutil
+
+import (
+	"log"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// StartCaptureTap monta o socket PAIR que serve de terceiro argumento para
+// zmq.Proxy (o padrao "listener_thread" do espresso) e uma goroutine que
+// espelha tudo recebido em um PUB externo bindado em mirrorAddr. Se o bind
+// falhar (ex.: porta ocupada), retorna nil sem capture - a observabilidade e
+// um extra, nao deve impedir o broker/proxy de rodar.
+func StartCaptureTap(mirrorAddr string) *zmq.Socket {
+	capture, err := zmq.NewSocket(zmq.PAIR)
+	if err != nil {
+		log.Println("Capture tap desabilitado, erro ao criar socket:", err)
+		return nil
+	}
+
+	if err := capture.Bind("inproc://capture"); err != nil {
+		log.Println("Capture tap desabilitado, erro ao fazer bind inproc://capture:", err)
+		capture.Close()
+		return nil
+	}
+
+	reader, err := zmq.NewSocket(zmq.PAIR)
+	if err != nil {
+		log.Println("Capture tap desabilitado, erro ao criar socket leitor:", err)
+		capture.Close()
+		return nil
+	}
+
+	if err := reader.Connect("inproc://capture"); err != nil {
+		log.Println("Capture tap desabilitado, erro ao conectar ao inproc://capture:", err)
+		capture.Close()
+		reader.Close()
+		return nil
+	}
+
+	mirror, err := zmq.NewSocket(zmq.PUB)
+	if err != nil {
+		log.Println("Capture tap desabilitado, erro ao criar socket mirror:", err)
+		capture.Close()
+		reader.Close()
+		return nil
+	}
+
+	if err := mirror.Bind(mirrorAddr); err != nil {
+		log.Println("Capture tap desabilitado, erro ao fazer bind em", mirrorAddr, ":", err)
+		capture.Close()
+		reader.Close()
+		mirror.Close()
+		return nil
+	}
+
+	log.Println("Capture tap ativo, espelhando trafego em", mirrorAddr, "(cmd/monitor)")
+
+	go func() {
+		defer reader.Close()
+		defer mirror.Close()
+		for {
+			frames, err := reader.RecvMessageBytes(0)
+			if err != nil {
+				log.Println("Capture tap encerrado:", err)
+				return
+			}
+			if _, err := mirror.SendMessage(frames); err != nil {
+				log.Println("Erro ao espelhar frames do capture tap:", err)
+			}
+		}
+	}()
+
+	return capture
+}
@@ -0,0 +1,70 @@
+package zmqutil
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// CurveDomain e o dominio ZAP usado por todos os sockets CURVE_SERVER do
+// broker e do proxy.
+const CurveDomain = "game"
+
+// loadCurveSecretKey le a chave secreta Z85 do servidor gerada por cmd/keygen.
+func loadCurveSecretKey(keysDir string) (string, error) {
+	return readKeyFile(filepath.Join(keysDir, "server.key_secret"))
+}
+
+// loadAuthorizedKeys le as chaves publicas Z85 de clientes autorizados, uma por
+// linha, ignorando linhas em branco e comentarios iniciados com '#'.
+func loadAuthorizedKeys(keysDir string) ([]string, error) {
+	f, err := os.Open(filepath.Join(keysDir, "authorized_keys.txt"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys, scanner.Err()
+}
+
+func readKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// StartCurveAuth inicia o handler ZAP do processo e autoriza as chaves de
+// authorized_keys.txt para CurveDomain. Deve ser chamado uma unica vez antes
+// de habilitar CURVE_SERVER em qualquer socket.
+func StartCurveAuth(keysDir string) error {
+	keys, err := loadAuthorizedKeys(keysDir)
+	if err != nil {
+		return err
+	}
+	zmq.AuthStart()
+	zmq.AuthCurveAdd(CurveDomain, keys...)
+	return nil
+}
+
+// EnableCurveServer ativa CURVE_SERVER no socket com a chave secreta em keysDir.
+func EnableCurveServer(sock *zmq.Socket, keysDir string) error {
+	secret, err := loadCurveSecretKey(keysDir)
+	if err != nil {
+		return err
+	}
+	return sock.ServerAuthCurve(CurveDomain, secret)
+}
@@ -0,0 +1,98 @@
+package zmqutil
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// Valores padrao de tuning - bem acima do default do zmq4 (1000 HWM, LINGER
+// infinito) para que um assinante lento derrube mensagens de forma visivel
+// (via log) ao inves de bloquear o processo indefinidamente no shutdown.
+const (
+	DefaultHWM    = 10000
+	DefaultBuf    = 0 // 0 = usa o default do SO
+	DefaultLinger = 1000 * time.Millisecond
+)
+
+// SocketTuning agrupa as opcoes de marca d'agua, buffers, linger e keepalive
+// aplicadas a um socket antes do bind, configuraveis por flag ou variavel de
+// ambiente (a flag, quando setada, tem prioridade sobre o env var).
+type SocketTuning struct {
+	Sndhwm       int
+	Rcvhwm       int
+	Sndbuf       int
+	Rcvbuf       int
+	Linger       time.Duration
+	TcpKeepAlive bool
+}
+
+func envInt(name string, def int) int {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func envBool(name string, def bool) bool {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def
+	}
+	return b
+}
+
+// DefaultTuning le os valores iniciais das flags a partir de variaveis de
+// ambiente, para que a flag de linha de comando (ja com esse default) continue
+// sendo a fonte da verdade em tempo de execucao.
+func DefaultTuning() SocketTuning {
+	return SocketTuning{
+		Sndhwm:       envInt("SNDHWM", DefaultHWM),
+		Rcvhwm:       envInt("RCVHWM", DefaultHWM),
+		Sndbuf:       envInt("SNDBUF", DefaultBuf),
+		Rcvbuf:       envInt("RCVBUF", DefaultBuf),
+		Linger:       time.Duration(envInt("LINGER_MS", int(DefaultLinger/time.Millisecond))) * time.Millisecond,
+		TcpKeepAlive: envBool("TCP_KEEPALIVE", true),
+	}
+}
+
+// Apply configura o socket com os valores de tuning. Deve ser chamado antes
+// do Bind/Connect, como exigido pelo zmq4 para a maioria dessas opcoes.
+func (t SocketTuning) Apply(sock *zmq.Socket) error {
+	if err := sock.SetSndhwm(t.Sndhwm); err != nil {
+		return err
+	}
+	if err := sock.SetRcvhwm(t.Rcvhwm); err != nil {
+		return err
+	}
+	if t.Sndbuf > 0 {
+		if err := sock.SetSndbuf(t.Sndbuf); err != nil {
+			return err
+		}
+	}
+	if t.Rcvbuf > 0 {
+		if err := sock.SetRcvbuf(t.Rcvbuf); err != nil {
+			return err
+		}
+	}
+	if err := sock.SetLinger(t.Linger); err != nil {
+		return err
+	}
+	if t.TcpKeepAlive {
+		if err := sock.SetTcpKeepalive(1); err != nil {
+			return err
+		}
+	}
+	return nil
+}